@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IdleTracker hooks an http.Server's ConnState callback to detect when the
+// server has zero open connections for longer than timeout, and cancels ctx
+// when that happens. It is useful for ephemeral/serverless deployments that
+// should shut themselves down once there's no more traffic to serve.
+//
+// IdleTracker is safe for concurrent use.
+type IdleTracker struct {
+	mu     sync.Mutex
+	active int
+	timer  *time.Timer
+
+	timeout time.Duration
+	cancel  context.CancelFunc
+}
+
+// NewIdleTracker returns an IdleTracker that calls cancel once timeout has
+// elapsed with no open connections. The timer starts armed immediately, so a
+// server that never receives a single connection still shuts down after
+// timeout instead of running forever.
+func NewIdleTracker(timeout time.Duration, cancel context.CancelFunc) *IdleTracker {
+	t := &IdleTracker{timeout: timeout, cancel: cancel}
+
+	t.mu.Lock()
+	t.armTimerLocked()
+	t.mu.Unlock()
+
+	return t
+}
+
+// ConnState is passed to http.Server.ConnState. It tracks StateNew/Closed/
+// Hijacked transitions to maintain the open connection count, arming the
+// idle timer whenever that count drops to zero and disarming it as soon as
+// a new connection arrives.
+func (t *IdleTracker) ConnState(_ net.Conn, state http.ConnState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch state {
+	case http.StateNew:
+		t.active++
+		t.stopTimerLocked()
+	case http.StateActive:
+		t.stopTimerLocked()
+	case http.StateIdle:
+		// connection kept alive awaiting the next request; open connection
+		// count is unchanged
+	case http.StateClosed, http.StateHijacked:
+		if t.active > 0 {
+			t.active--
+		}
+		if t.active == 0 {
+			t.armTimerLocked()
+		}
+	}
+}
+
+// ActiveConnections reports the number of currently open connections.
+func (t *IdleTracker) ActiveConnections() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.active
+}
+
+func (t *IdleTracker) armTimerLocked() {
+	t.timer = time.AfterFunc(t.timeout, t.cancel)
+}
+
+func (t *IdleTracker) stopTimerLocked() {
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+}
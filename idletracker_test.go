@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIdleTrackerFiresWithNoConnections(t *testing.T) {
+	cancelled := make(chan struct{})
+	NewIdleTracker(10*time.Millisecond, func() { close(cancelled) })
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("expected cancel to fire after timeout with no connections ever opened")
+	}
+}
+
+func TestIdleTrackerResetsOnActivity(t *testing.T) {
+	cancelled := make(chan struct{})
+	tracker := NewIdleTracker(50*time.Millisecond, func() { close(cancelled) })
+
+	tracker.ConnState(nil, http.StateNew)
+	if got := tracker.ActiveConnections(); got != 1 {
+		t.Fatalf("ActiveConnections() = %d, want 1", got)
+	}
+
+	select {
+	case <-cancelled:
+		t.Fatal("cancel fired while a connection was still open")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	tracker.ConnState(nil, http.StateClosed)
+	if got := tracker.ActiveConnections(); got != 0 {
+		t.Fatalf("ActiveConnections() = %d, want 0", got)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("expected cancel to fire after the last connection closed")
+	}
+}
+
+func TestIdleTrackerConcurrentConnState(t *testing.T) {
+	tracker := NewIdleTracker(time.Hour, func() {})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tracker.ConnState(nil, http.StateNew)
+			tracker.ConnState(nil, http.StateActive)
+			tracker.ConnState(nil, http.StateClosed)
+		}()
+	}
+	wg.Wait()
+
+	if got := tracker.ActiveConnections(); got != 0 {
+		t.Fatalf("ActiveConnections() = %d, want 0 after all connections closed", got)
+	}
+}
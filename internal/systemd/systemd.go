@@ -0,0 +1,86 @@
+// Package systemd implements the small subset of the systemd socket
+// activation (sd_listen_fds) and service notification (sd_notify) protocols
+// that this service needs, so main.go doesn't have to know about either.
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// listenFDsStart is the first file descriptor systemd passes to activated
+// services, per the sd_listen_fds protocol.
+const listenFDsStart = 3
+
+// Ready and Stopping are the states sent to NOTIFY_SOCKET via Notify.
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+)
+
+// Listener returns the first socket passed to this process by systemd, if
+// any. ok is false when no socket was passed (LISTEN_FDS/LISTEN_PID unset or
+// not addressed to this process), in which case callers should fall back to
+// net.Listen.
+func Listener() (listener net.Listener, ok bool, err error) {
+	fds, err := listenFDs()
+	if err != nil || fds == 0 {
+		return nil, false, err
+	}
+
+	file := os.NewFile(uintptr(listenFDsStart), "LISTEN_FD_3")
+	defer file.Close()
+
+	listener, err = net.FileListener(file)
+	if err != nil {
+		return nil, false, fmt.Errorf("systemd: could not use inherited listener: %w", err)
+	}
+
+	return listener, true, nil
+}
+
+// Notify sends state to the socket named by NOTIFY_SOCKET. It is a no-op if
+// NOTIFY_SOCKET isn't set, which is the case whenever the service wasn't
+// started by systemd.
+func Notify(state string) error {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return nil
+	}
+
+	conn, err := net.Dial("unixgram", addr)
+	if err != nil {
+		return fmt.Errorf("systemd: could not dial NOTIFY_SOCKET: %w", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// listenFDs returns the number of sockets systemd passed to this process,
+// or 0 if none were passed or LISTEN_PID doesn't match the current process.
+func listenFDs() (int, error) {
+	pidStr := os.Getenv("LISTEN_PID")
+	fdsStr := os.Getenv("LISTEN_FDS")
+	if pidStr == "" || fdsStr == "" {
+		return 0, nil
+	}
+
+	pid, err := strconv.Atoi(pidStr)
+	if err != nil {
+		return 0, fmt.Errorf("systemd: invalid LISTEN_PID: %w", err)
+	}
+	if pid != os.Getpid() {
+		return 0, nil
+	}
+
+	fds, err := strconv.Atoi(fdsStr)
+	if err != nil {
+		return 0, fmt.Errorf("systemd: invalid LISTEN_FDS: %w", err)
+	}
+
+	return fds, nil
+}
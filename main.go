@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"net/http/pprof"
 	"os"
 	"os/signal"
 	"syscall"
@@ -10,12 +12,14 @@ import (
 
 	"github.com/gorilla/mux"
 	cli "github.com/jawher/mow.cli"
-	metrics "github.com/rcrowley/go-metrics"
+	"golang.org/x/sync/errgroup"
 
 	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
 	logger "github.com/Financial-Times/go-logger/v2"
-	"github.com/Financial-Times/http-handlers-go/v2/httphandlers"
 	status "github.com/Financial-Times/service-status-go/httphandlers"
+
+	"github.com/ivan-p-nikolov/cm-go-service/pkg/metrics"
+	"github.com/ivan-p-nikolov/cm-go-service/pkg/middleware"
 )
 
 const (
@@ -25,6 +29,7 @@ const (
 	httpServerWriteTimeout = 15 * time.Second
 	httpServerIdleTimeout  = 20 * time.Second
 	httpHandlersTimeout    = 14 * time.Second
+	shutdownGracePeriod    = 30 * time.Second
 )
 
 func main() {
@@ -51,6 +56,13 @@ func main() {
 		EnvVar: "APP_PORT",
 	})
 
+	introspectionPort := app.String(cli.StringOpt{
+		Name:   "introspection-port",
+		Value:  "8081",
+		Desc:   "port serving health checks, build info, metrics and pprof profiling",
+		EnvVar: "APP_INTROSPECTION_PORT",
+	})
+
 	logLevel := app.String(cli.StringOpt{
 		Name:   "log-level",
 		Value:  "INFO",
@@ -58,6 +70,13 @@ func main() {
 		EnvVar: "LOG_LEVEL",
 	})
 
+	idleTimeout := app.Int(cli.IntOpt{
+		Name:   "idle-timeout",
+		Value:  0,
+		Desc:   "shut down after this many seconds with zero active connections (0 disables idle shutdown)",
+		EnvVar: "IDLE_TIMEOUT",
+	})
+
 	log := logger.NewUPPLogger(*appName, *logLevel)
 
 	app.Action = func() {
@@ -65,13 +84,34 @@ func main() {
 
 		healthService := NewHealthService(*appSystemCode, *appName, appDescription)
 
-		router := registerEndpoints(healthService, log)
-
+		router := registerEndpoints(log)
 		server := newHTTPServer(*port, router)
-		go startHTTPServer(server, log)
 
-		waitForSignal()
-		stopHTTPServer(server, log)
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		var idleTracker *IdleTracker
+		if *idleTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithCancel(ctx)
+
+			idleTracker = NewIdleTracker(time.Duration(*idleTimeout)*time.Second, cancel)
+			server.ConnState = idleTracker.ConnState
+
+			log.Infof("idle shutdown enabled: will stop after %ds with no active connections", *idleTimeout)
+		}
+
+		introspectionRouter := registerIntrospectionEndpoints(healthService, idleTracker)
+		introspectionServer := newIntrospectionServer(*introspectionPort, introspectionRouter)
+
+		runnables := []Runnable{
+			newSocketActivatedHTTPServerRunnable(server),
+			newHTTPServerRunnable(introspectionServer),
+		}
+
+		if err := run(ctx, runnables, log); err != nil {
+			log.Errorf("app could not run: %v", err)
+		}
 	}
 
 	err := app.Run(os.Args)
@@ -81,27 +121,58 @@ func main() {
 	}
 }
 
-func registerEndpoints(healthService *HealthService, log *logger.UPPLogger) http.Handler {
+// registerEndpoints wires up the endpoints specific to this service. It no
+// longer needs to special-case supervisory paths: those are served from a
+// separate introspection server, so every request here goes through the
+// full decorator pipeline.
+func registerEndpoints(log *logger.UPPLogger) http.Handler {
+	servicesRouter := mux.NewRouter()
+	// metrics must be registered on the router itself (not the outer
+	// pipeline) so it runs after mux has matched a route onto the request
+	// and mux.CurrentRoute is populated for the route label
+	servicesRouter.Use(mux.MiddlewareFunc(middleware.Metrics()))
+	//TODO: add real handlers
+	servicesRouter.HandleFunc("/test", TestHandler).Methods("GET")
+
+	// wrap the handler with the standard pipeline of decorators: panic recovery,
+	// request ID injection, transaction-aware logging and a timeout
+	pipeline := middleware.New(
+		middleware.Recovery(log),
+		middleware.RequestID(),
+		middleware.Timeout(httpHandlersTimeout, ""),
+		middleware.Logging(log),
+	)
+
+	return pipeline.Decorate(servicesRouter)
+}
+
+// registerIntrospectionEndpoints wires up the health checks, build info,
+// metrics and pprof profiling endpoints served on the dedicated
+// introspection port, away from public traffic. idleTracker is nil unless
+// idle-timeout shutdown is enabled, in which case its active connection
+// count is also exposed here.
+func registerIntrospectionEndpoints(healthService *HealthService, idleTracker *IdleTracker) http.Handler {
 	serveMux := http.NewServeMux()
 
-	// register supervisory endpoint that does not require logging and metrics collection
 	serveMux.HandleFunc("/__health", fthealth.Handler(healthService.Health()))
 	serveMux.HandleFunc(status.GTGPath, status.NewGoodToGoHandler(healthService.GTG))
 	serveMux.HandleFunc(status.BuildInfoPath, status.BuildInfoHandler)
+	serveMux.Handle("/__metrics", metrics.Handler())
+
+	// the active connection count is reported on its own /__idle-status
+	// endpoint; it is not added as a check to /__health
+	if idleTracker != nil {
+		serveMux.HandleFunc("/__idle-status", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			fmt.Fprintf(w, "active connections: %d\n", idleTracker.ActiveConnections())
+		})
+	}
 
-	// add services router and register endpoints specific to this service only
-	servicesRouter := mux.NewRouter()
-	//TODO: add real handlers
-	servicesRouter.HandleFunc("/test", TestHandler).Methods("GET")
-
-	// wrap the handler with certain middlewares providing logging of the requests,
-	// sending metrics and handler time out on certain time interval
-	var wrappedServicesRouter http.Handler = servicesRouter
-	wrappedServicesRouter = httphandlers.TransactionAwareRequestLoggingHandler(log, wrappedServicesRouter)
-	wrappedServicesRouter = httphandlers.HTTPMetricsHandler(metrics.DefaultRegistry, wrappedServicesRouter)
-	wrappedServicesRouter = http.TimeoutHandler(wrappedServicesRouter, httpHandlersTimeout, "")
-
-	serveMux.Handle("/", wrappedServicesRouter)
+	serveMux.HandleFunc("/debug/pprof/", pprof.Index)
+	serveMux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	serveMux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	serveMux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	serveMux.HandleFunc("/debug/pprof/trace", pprof.Trace)
 
 	return serveMux
 }
@@ -116,25 +187,49 @@ func newHTTPServer(port string, router http.Handler) *http.Server {
 	}
 }
 
-func startHTTPServer(server *http.Server, log *logger.UPPLogger) {
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("http server failed to start: %v", err)
+// newIntrospectionServer builds the server for health checks, build info,
+// metrics and pprof. It deliberately does not share newHTTPServer's
+// WriteTimeout: pprof's CPU profile and trace handlers block for as long as
+// the caller's ?seconds= asks (up to 30s by default), and the public
+// service's 15s write deadline would kill those connections before they can
+// respond.
+func newIntrospectionServer(port string, router http.Handler) *http.Server {
+	return &http.Server{
+		Addr:        ":" + port,
+		Handler:     router,
+		ReadTimeout: httpServerReadTimeout,
+		IdleTimeout: httpServerIdleTimeout,
 	}
 }
 
-func stopHTTPServer(server *http.Server, log *logger.UPPLogger) {
-	log.Info("http server is shutting down...")
+// run starts every runnable and supervises them with an errgroup: cancelling
+// ctx (on a SIGINT, SIGTERM, idle timeout, or any runnable's error) triggers
+// a coordinated, bounded-grace-period shutdown of all runnables.
+func run(ctx context.Context, runnables []Runnable, log *logger.UPPLogger) error {
+	g, gctx := errgroup.WithContext(ctx)
+
+	for _, r := range runnables {
+		r := r
+		g.Go(func() error {
+			return r.Run(gctx)
+		})
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	g.Go(func() error {
+		<-gctx.Done()
+		log.Info("shutdown signal received, stopping runnables...")
 
-	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("failed to gracefully shutdown the server: %v", err)
-	}
-}
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownGracePeriod)
+		defer cancel()
+
+		for _, r := range runnables {
+			if err := r.Shutdown(shutdownCtx); err != nil {
+				log.Errorf("failed to gracefully shut down runnable: %v", err)
+			}
+		}
+
+		return nil
+	})
 
-func waitForSignal() {
-	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
-	<-ch
+	return g.Wait()
 }
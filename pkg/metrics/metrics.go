@@ -0,0 +1,80 @@
+// Package metrics provides a Prometheus-backed HTTP middleware and handler
+// for the service's request metrics, replacing the previously write-only
+// rcrowley/go-metrics registry.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Total number of HTTP requests handled, labelled by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request duration in seconds, labelled by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+)
+
+// Handler returns an http.Handler that serves the registered metrics in the
+// Prometheus text exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware records per-request counters and duration histograms for the
+// wrapped handler. The route label is taken from the matched mux.Route
+// template so that path parameters don't explode cardinality: it must
+// therefore be installed as mux.Router.Use middleware, so that it runs with
+// the request object mux has already matched a route onto, rather than as
+// an outer decorator wrapping the router itself.
+//
+// Recording happens in a defer so a panic in the wrapped handler still
+// produces a metric instead of silently skipping it.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		defer func() {
+			route := routeTemplate(r)
+			requestsTotal.WithLabelValues(r.Method, route, strconv.Itoa(rec.status)).Inc()
+			requestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// statusRecorder wraps an http.ResponseWriter to capture the status code
+// passed to WriteHeader, defaulting to 200 if the handler never calls it
+// explicitly.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return "unmatched"
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatusRecorderDefaultsTo200(t *testing.T) {
+	var rec *statusRecorder
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec = w.(*statusRecorder)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.status, http.StatusOK)
+	}
+}
+
+func TestStatusRecorderCapturesWriteHeader(t *testing.T) {
+	underlying := httptest.NewRecorder()
+	rec := &statusRecorder{ResponseWriter: underlying, status: http.StatusOK}
+
+	rec.WriteHeader(http.StatusTeapot)
+
+	if rec.status != http.StatusTeapot {
+		t.Fatalf("rec.status = %d, want %d", rec.status, http.StatusTeapot)
+	}
+	if underlying.Code != http.StatusTeapot {
+		t.Fatalf("underlying recorder code = %d, want %d", underlying.Code, http.StatusTeapot)
+	}
+}
+
+func TestRouteTemplateFallsBackToUnmatched(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/anything", nil)
+
+	if got := routeTemplate(req); got != "unmatched" {
+		t.Fatalf("routeTemplate() = %q, want %q", got, "unmatched")
+	}
+}
+
+func TestMiddlewarePropagatesStatusAndBody(t *testing.T) {
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("ok"))
+	}))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/widgets", nil)
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusCreated)
+	}
+	if rec.Body.String() != "ok" {
+		t.Fatalf("body = %q, want %q", rec.Body.String(), "ok")
+	}
+}
+
+func TestMiddlewareDefaultsStatusTo200WhenHandlerNeverWrites(t *testing.T) {
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/noop", nil)
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
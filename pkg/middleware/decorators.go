@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	logger "github.com/Financial-Times/go-logger/v2"
+	"github.com/Financial-Times/http-handlers-go/v2/httphandlers"
+	tidutils "github.com/Financial-Times/transactionid-utils-go"
+
+	"github.com/ivan-p-nikolov/cm-go-service/pkg/metrics"
+)
+
+// RequestID ensures every request carries a transaction ID, generating one
+// when the caller didn't supply it, and returns it on the response so
+// callers can correlate logs.
+func RequestID() Decorator {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tid := tidutils.GetTransactionIDFromRequest(r)
+			if tid == "" {
+				tid = tidutils.NewTransactionID()
+			}
+
+			r = r.WithContext(tidutils.TransactionAwareContext(r.Context(), tid))
+			w.Header().Set(tidutils.TransactionIDHeader, tid)
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Recovery recovers from panics in the wrapped handler, logging the stack
+// trace and responding with a 500 instead of crashing the process.
+func Recovery(log *logger.UPPLogger) Decorator {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				if rec := recover(); rec != nil {
+					log.Errorf("panic recovered handling %s %s: %v\n%s", r.Method, r.URL.Path, rec, debug.Stack())
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Logging decorates the handler with transaction-aware request logging.
+func Logging(log *logger.UPPLogger) Decorator {
+	return func(next http.Handler) http.Handler {
+		return httphandlers.TransactionAwareRequestLoggingHandler(log, next)
+	}
+}
+
+// Metrics decorates the handler with Prometheus request metrics.
+func Metrics() Decorator {
+	return metrics.Middleware
+}
+
+// Timeout cancels the handler and responds with a 503 and msg if it runs
+// longer than d. An empty msg gets http.TimeoutHandler's default HTML body.
+func Timeout(d time.Duration, msg string) Decorator {
+	return func(next http.Handler) http.Handler {
+		return http.TimeoutHandler(next, d, msg)
+	}
+}
+
+// CORS allows cross-origin requests from the given origins, answering
+// preflight OPTIONS requests directly. Pass "*" to allow any origin.
+func CORS(allowedOrigins ...string) Decorator {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if origin := r.Header.Get("Origin"); origin != "" && originAllowed(origin, allowedOrigins) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+tidutils.TransactionIDHeader)
+			}
+
+			if r.Method == http.MethodOptions {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func originAllowed(origin string, allowed []string) bool {
+	for _, a := range allowed {
+		if a == "*" || a == origin {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+// Package middleware provides a small alice-style decorator pipeline used to
+// compose the service's HTTP handler chain declaratively instead of
+// re-assigning a wrappedServicesRouter variable by hand in main.go.
+package middleware
+
+import "net/http"
+
+// Decorator wraps an http.Handler with additional behaviour.
+type Decorator func(http.Handler) http.Handler
+
+// Pipeline composes a fixed, ordered set of Decorators.
+type Pipeline struct {
+	decorators []Decorator
+}
+
+// New builds a Pipeline from the given decorators. The first decorator is
+// the outermost one: it sees the request before, and the response after,
+// every other decorator in the chain.
+func New(decorators ...Decorator) *Pipeline {
+	return &Pipeline{decorators: decorators}
+}
+
+// Decorate wraps handler with every decorator in the pipeline, outermost
+// first.
+func (p *Pipeline) Decorate(handler http.Handler) http.Handler {
+	for i := len(p.decorators) - 1; i >= 0; i-- {
+		handler = p.decorators[i](handler)
+	}
+	return handler
+}
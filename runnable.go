@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/ivan-p-nikolov/cm-go-service/internal/systemd"
+)
+
+// Runnable is a long-running component supervised by main's errgroup. Run
+// should block until ctx is cancelled or an unrecoverable error occurs;
+// Shutdown should bring the component down within the bounds of the context
+// passed to it.
+type Runnable interface {
+	Run(ctx context.Context) error
+	Shutdown(ctx context.Context) error
+}
+
+// httpServerRunnable adapts an *http.Server to the Runnable interface.
+type httpServerRunnable struct {
+	server          *http.Server
+	socketActivated bool
+}
+
+// newHTTPServerRunnable wraps server for plain net.Listen-based serving.
+func newHTTPServerRunnable(server *http.Server) *httpServerRunnable {
+	return &httpServerRunnable{server: server}
+}
+
+// newSocketActivatedHTTPServerRunnable wraps server so that Run prefers a
+// listener inherited from systemd (sd_listen_fds) over net.Listen, and
+// sends sd_notify READY/STOPPING messages around its lifecycle.
+func newSocketActivatedHTTPServerRunnable(server *http.Server) *httpServerRunnable {
+	return &httpServerRunnable{server: server, socketActivated: true}
+}
+
+func (r *httpServerRunnable) Run(ctx context.Context) error {
+	listener, err := r.listener()
+	if err != nil {
+		return err
+	}
+
+	if r.socketActivated {
+		_ = systemd.Notify(systemd.Ready)
+	}
+
+	if err := r.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (r *httpServerRunnable) Shutdown(ctx context.Context) error {
+	if r.socketActivated {
+		_ = systemd.Notify(systemd.Stopping)
+	}
+	return r.server.Shutdown(ctx)
+}
+
+// listener returns the first systemd-inherited listener when socket
+// activation is enabled and a socket was actually passed to the process,
+// falling back to a plain net.Listen on the server's Addr otherwise. The
+// inherited socket is used as is; its address is not compared with Addr.
+func (r *httpServerRunnable) listener() (net.Listener, error) {
+	if r.socketActivated {
+		if listener, ok, err := systemd.Listener(); err != nil {
+			return nil, err
+		} else if ok {
+			return listener, nil
+		}
+	}
+
+	return net.Listen("tcp", r.server.Addr)
+}